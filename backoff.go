@@ -0,0 +1,91 @@
+package retry
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+// RetryAfterBackOff wraps a BackOff and, on each attempt, takes into account
+// the Retry-After header (RFC 7231, both the delta-seconds and HTTP-date
+// forms) of the last response. The actual sleep duration is
+// max(server-hint, wrapped.NextBackOff()), so a server's explicit hint can
+// only lengthen the wait, never shorten it below the backoff policy's own
+// floor.
+type RetryAfterBackOff struct {
+	BackOff
+
+	mu   sync.Mutex
+	resp *http.Response
+}
+
+// NewRetryAfterBackOff wraps b so it honors the Retry-After header of the
+// last response observed via setResponse.
+func NewRetryAfterBackOff(b BackOff) *RetryAfterBackOff {
+	return &RetryAfterBackOff{BackOff: b}
+}
+
+// NextBackOff returns the longer of the wrapped policy's own delay and the
+// server's Retry-After hint, if any.
+func (b *RetryAfterBackOff) NextBackOff() time.Duration {
+	next := b.BackOff.NextBackOff()
+	if next == backoff.Stop {
+		return backoff.Stop
+	}
+	b.mu.Lock()
+	resp := b.resp
+	b.mu.Unlock()
+	if resp == nil {
+		return next
+	}
+	if hint, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok && hint > next {
+		return hint
+	}
+	return next
+}
+
+// setResponse records the response of the last attempt, so the next call to
+// NextBackOff can honor its Retry-After header.
+func (b *RetryAfterBackOff) setResponse(resp *http.Response) {
+	b.mu.Lock()
+	b.resp = resp
+	b.mu.Unlock()
+}
+
+// parseRetryAfter parses a Retry-After header value in either the
+// delta-seconds or the HTTP-date form described by RFC 7231 section 7.1.3.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// WithJitter returns a backoff policy identical to DefaultBackOff but with
+// the given randomization factor, so callers can tune how much jitter is
+// added to each interval to avoid a thundering herd.
+func WithJitter(factor float64) BackOff {
+	b := backoff.WithMaxRetries(&backoff.ExponentialBackOff{
+		InitialInterval:     1 * time.Second,
+		RandomizationFactor: factor,
+		Multiplier:          2,
+		MaxInterval:         60 * time.Second,
+		Clock:               backoff.SystemClock,
+	}, DefaultMaxRetry)
+	b.Reset()
+	return b
+}
@@ -0,0 +1,47 @@
+package retry_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golovers/retry"
+)
+
+func TestRetryAfterBackOffHonorsHeader(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	var firstAttempt, secondAttempt time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			cnt++
+		}()
+		if cnt == 0 {
+			firstAttempt = time.Now()
+			// WithJitter(0) waits 1s on its own, so a 2s hint is what
+			// tells whether the header was honored.
+			w.Header().Set("Retry-After", "2")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		secondAttempt = time.Now()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	c := retry.New()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	b := retry.NewRetryAfterBackOff(retry.WithJitter(0))
+	if _, err := c.DoWithCheckRetry(req, b, retry.DefaultCheckRetry); err != nil {
+		t.Errorf("DoWithCheckRetry(req) got error=%v, want error=nil", err)
+	}
+	if cnt != 2 {
+		t.Errorf("DoWithCheckRetry(req) executed %d times, want 2 times", cnt)
+	}
+	if secondAttempt.Sub(firstAttempt) < 2*time.Second {
+		t.Errorf("second attempt happened %v after the first, want >= 2s (Retry-After honored)", secondAttempt.Sub(firstAttempt))
+	}
+}
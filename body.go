@@ -0,0 +1,63 @@
+package retry
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// LenReader is implemented by request bodies that know their own length.
+// Mirroring the go-retryablehttp convention lets callers keep Content-Length
+// accurate across retries even for bodies that aren't buffered up-front.
+type LenReader interface {
+	Len() int
+}
+
+// rewinder resets a request's body back to its start before an attempt.
+// Built by prepareBody, which picks the cheapest strategy available for the
+// given request so large bodies (multi-GB S3/GCS PUTs, image pushes) don't
+// need to be buffered into memory just to be retried.
+type rewinder func() error
+
+// prepareBody returns a rewinder for r.Body, preferring, in order:
+//  1. r.GetBody, which http.NewRequest already sets for *bytes.Buffer,
+//     *bytes.Reader and *strings.Reader bodies;
+//  2. seeking the body back to the start, if it implements io.Seeker;
+//  3. buffering the whole body into memory, as a last resort.
+func prepareBody(r *http.Request) (rewinder, error) {
+	if r.Body == nil {
+		return func() error { return nil }, nil
+	}
+	if r.GetBody != nil {
+		return func() error {
+			body, err := r.GetBody()
+			if err != nil {
+				return err
+			}
+			r.Body = body
+			return nil
+		}, nil
+	}
+	if seeker, ok := r.Body.(io.ReadSeeker); ok {
+		return func() error {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			if lr, ok := seeker.(LenReader); ok {
+				r.ContentLength = int64(lr.Len())
+			}
+			r.Body = ioutil.NopCloser(seeker)
+			return nil
+		}, nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	r.Body.Close()
+	return func() error {
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		return nil
+	}, nil
+}
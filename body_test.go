@@ -0,0 +1,103 @@
+package retry_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golovers/retry"
+)
+
+func TestDoRetriesWithBufferBody(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	var gotBodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			cnt++
+		}()
+		b, _ := ioutil.ReadAll(r.Body)
+		gotBodies = append(gotBodies, string(b))
+		if cnt == 0 {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	c := retry.New()
+	req, err := http.NewRequest(http.MethodPost, ts.URL, bytes.NewBufferString("payload"))
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	if _, err := c.Do(req); err != nil {
+		t.Errorf("Do(req) got error=%v, want error=nil", err)
+	}
+	if cnt != 2 {
+		t.Errorf("Do(req) executed %d times, want 2 times", cnt)
+	}
+	for _, b := range gotBodies {
+		if b != "payload" {
+			t.Errorf("got body %q on an attempt, want %q on every attempt", b, "payload")
+		}
+	}
+}
+
+// readSeekCloser is an io.ReadCloser that also implements io.Seeker and
+// LenReader, like a large file body would. Since it isn't one of the types
+// http.NewRequest special-cases (*bytes.Buffer, *bytes.Reader,
+// *strings.Reader), it leaves req.GetBody nil and exercises prepareBody's
+// io.ReadSeeker fallback instead.
+type readSeekCloser struct {
+	*bytes.Reader
+}
+
+func (readSeekCloser) Close() error { return nil }
+
+func TestDoRetriesWithSeekableBody(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	var gotBodies []string
+	var gotContentLengths []int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			cnt++
+		}()
+		b, _ := ioutil.ReadAll(r.Body)
+		gotBodies = append(gotBodies, string(b))
+		gotContentLengths = append(gotContentLengths, r.ContentLength)
+		if cnt == 0 {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	c := retry.New()
+	body := readSeekCloser{bytes.NewReader([]byte("payload"))}
+	req, err := http.NewRequest(http.MethodPost, ts.URL, body)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	if req.GetBody != nil {
+		t.Fatalf("req.GetBody is set, want nil so the test exercises the io.ReadSeeker fallback")
+	}
+	if _, err := c.Do(req); err != nil {
+		t.Errorf("Do(req) got error=%v, want error=nil", err)
+	}
+	if cnt != 2 {
+		t.Errorf("Do(req) executed %d times, want 2 times", cnt)
+	}
+	for i, b := range gotBodies {
+		if b != "payload" {
+			t.Errorf("attempt %d: got body %q, want %q", i, b, "payload")
+		}
+	}
+	for i, l := range gotContentLengths {
+		if l != int64(len("payload")) {
+			t.Errorf("attempt %d: got Content-Length %d, want %d", i, l, len("payload"))
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package retry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// redirectsErrorRe matches the *url.Error Go's net/http client returns once
+// it gives up following a redirect chain, e.g. "stopped after 10 redirects".
+var redirectsErrorRe = regexp.MustCompile(`stopped after \d+ redirects\z`)
+
+// malformedLocationErrorRe matches the *url.Error Go's net/http client
+// returns when a server's Location header doesn't parse as a URL.
+var malformedLocationErrorRe = regexp.MustCompile(`failed to parse Location header`)
+
+// CheckRetry is a function that decides whether a request should be retried
+// based on both the response and the transport error of the last attempt.
+// Unlike Func, it can see errors such as x509.UnknownAuthorityError or
+// context.Canceled, which a retry policy must never retry on. A non-nil
+// error return is treated as a permanent error and aborts the retry loop
+// immediately, regardless of the bool.
+type CheckRetry func(resp *http.Response, err error) (bool, error)
+
+// asCheckRetry adapts the legacy Func type to a CheckRetry, preserving the
+// original DoWithRetryFunc behavior: a transport error always triggers a
+// retry (Func never saw it), while a response is only retried when f
+// reports true.
+func asCheckRetry(f Func) CheckRetry {
+	return func(resp *http.Response, err error) (bool, error) {
+		if err != nil {
+			return true, nil
+		}
+		return f(resp), nil
+	}
+}
+
+// DoWithCheckRetry execute the given request with the given backoff policy.
+// A retry is determined by the given CheckRetry, which unlike Func also
+// gets to see the transport error of the last attempt.
+func (c *Client) DoWithCheckRetry(r *http.Request, b BackOff, check CheckRetry) (*http.Response, error) {
+	return c.DoWithCheckRetryContext(context.Background(), r, b, check)
+}
+
+// DoWithCheckRetryContext is like DoWithCheckRetry but cancellable via ctx.
+// See DoWithContext for the context semantics.
+func (c *Client) DoWithCheckRetryContext(ctx context.Context, r *http.Request, b BackOff, check CheckRetry) (*http.Response, error) {
+	return c.doWithCheckRetryContext(ctx, r, b, check)
+}
+
+// DefaultCheckRetry retries on connection errors and 5xx responses (except
+// http.StatusNotImplemented), but never on non-retriable TLS/x509 errors,
+// exhausted redirect chains, malformed redirect Location headers, or
+// context cancellation. It also retries on http.StatusTooManyRequests,
+// matching the behavior most rate-limited APIs expect.
+func DefaultCheckRetry(resp *http.Response, err error) (bool, error) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false, err
+		}
+		if v, ok := err.(*url.Error); ok {
+			if redirectsErrorRe.MatchString(v.Err.Error()) {
+				return false, v.Err
+			}
+			if malformedLocationErrorRe.MatchString(v.Err.Error()) {
+				return false, v.Err
+			}
+			if isCertError(v.Err) {
+				return false, v.Err
+			}
+		}
+		return true, nil
+	}
+	if resp.StatusCode == 0 || (resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented) {
+		return true, nil
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true, nil
+	}
+	return false, nil
+}
+
+// RetryOn4xxNonceErrors retries http.StatusTooManyRequests and the
+// ACME-style "bad nonce" case, where a server rejects a request with
+// http.StatusBadRequest but hands back a fresh Replay-Nonce header to retry
+// with, per the golang.org/x/crypto/acme retry model.
+func RetryOn4xxNonceErrors(resp *http.Response, err error) (bool, error) {
+	if err != nil {
+		return DefaultCheckRetry(resp, err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true, nil
+	}
+	if resp.StatusCode == http.StatusBadRequest && resp.Header.Get("Replay-Nonce") != "" {
+		return true, nil
+	}
+	return false, nil
+}
+
+// NeverRetry never retries: the first response is returned as-is, and a
+// transport error of the first attempt ends the call with an error.
+func NeverRetry(resp *http.Response, err error) (bool, error) {
+	return false, err
+}
+
+// isCertError reports whether err is, or wraps, one of the TLS/x509 errors
+// that can never be fixed by retrying. Since Go 1.20, *http.Client.Do
+// reports these inside a *tls.CertificateVerificationError rather than
+// surfacing them directly, so the x509 types are matched with errors.As to
+// also catch them nested one level down.
+func isCertError(err error) bool {
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		return true
+	}
+	var certInvalid x509.CertificateInvalidError
+	if errors.As(err, &certInvalid) {
+		return true
+	}
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) {
+		return true
+	}
+	var verifyErr *tls.CertificateVerificationError
+	return errors.As(err, &verifyErr)
+}
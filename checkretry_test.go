@@ -0,0 +1,183 @@
+package retry_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golovers/retry"
+)
+
+func TestDoWithCheckRetryNeverRetry(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cnt++
+		http.Error(w, "server error", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	c := retry.New()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	rs, err := c.DoWithCheckRetry(req, retry.DefaultBackOff(), retry.NeverRetry)
+	if err != nil {
+		t.Errorf("DoWithCheckRetry(req) got error=%v, want error=nil", err)
+	}
+	if rs.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got status=%d, want %d", rs.StatusCode, http.StatusInternalServerError)
+	}
+	if cnt != 1 {
+		t.Errorf("DoWithCheckRetry(req) executed %d times, want 1 time", cnt)
+	}
+}
+
+func TestDefaultCheckRetryRetriesTooManyRequests(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			cnt++
+		}()
+		if cnt == 0 {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	c := retry.New()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	rs, err := c.DoWithCheckRetry(req, retry.DefaultBackOff(), retry.DefaultCheckRetry)
+	if err != nil {
+		t.Errorf("DoWithCheckRetry(req) got error=%v, want error=nil", err)
+	}
+	if rs.StatusCode != http.StatusOK {
+		t.Errorf("got status=%d, want %d", rs.StatusCode, http.StatusOK)
+	}
+	if cnt != 2 {
+		t.Errorf("DoWithCheckRetry(req) executed %d times, want 2 times", cnt)
+	}
+}
+
+func TestDefaultCheckRetryNeverRetriesUntrustedCert(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cnt++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	// An empty cert pool makes the client reject ts's certificate with an
+	// x509.UnknownAuthorityError wrapped in a *tls.CertificateVerificationError.
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: x509.NewCertPool()},
+		},
+	}
+	c := retry.NewWithClient(httpClient).WithLogger(noopLogger{})
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	if _, err := c.DoWithCheckRetry(req, retry.DefaultBackOff(), retry.DefaultCheckRetry); err == nil {
+		t.Errorf("DoWithCheckRetry(req) got error=nil, want error != nil")
+	}
+	if cnt != 0 {
+		t.Errorf("server handled %d requests, want 0 (untrusted cert error must not be retried)", cnt)
+	}
+}
+
+func TestRetryOn4xxNonceErrorsRetriesBadNonce(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			cnt++
+		}()
+		if cnt == 0 {
+			w.Header().Set("Replay-Nonce", "a-fresh-nonce")
+			http.Error(w, `{"type":"urn:ietf:params:acme:error:badNonce"}`, http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	c := retry.New()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	rs, err := c.DoWithCheckRetry(req, retry.DefaultBackOff(), retry.RetryOn4xxNonceErrors)
+	if err != nil {
+		t.Errorf("DoWithCheckRetry(req) got error=%v, want error=nil", err)
+	}
+	if rs.StatusCode != http.StatusOK {
+		t.Errorf("got status=%d, want %d", rs.StatusCode, http.StatusOK)
+	}
+	if cnt != 2 {
+		t.Errorf("DoWithCheckRetry(req) executed %d times, want 2 times", cnt)
+	}
+}
+
+func TestRetryOn4xxNonceErrorsNeverRetriesBadRequestWithoutNonce(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cnt++
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer ts.Close()
+	c := retry.New()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	rs, err := c.DoWithCheckRetry(req, retry.DefaultBackOff(), retry.RetryOn4xxNonceErrors)
+	if err != nil {
+		t.Errorf("DoWithCheckRetry(req) got error=%v, want error=nil", err)
+	}
+	if rs.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status=%d, want %d", rs.StatusCode, http.StatusBadRequest)
+	}
+	if cnt != 1 {
+		t.Errorf("DoWithCheckRetry(req) executed %d times, want 1 time (no Replay-Nonce header means no retry)", cnt)
+	}
+}
+
+func TestRetryOn4xxNonceErrorsRetriesTooManyRequests(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			cnt++
+		}()
+		if cnt == 0 {
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	c := retry.New()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	rs, err := c.DoWithCheckRetry(req, retry.DefaultBackOff(), retry.RetryOn4xxNonceErrors)
+	if err != nil {
+		t.Errorf("DoWithCheckRetry(req) got error=%v, want error=nil", err)
+	}
+	if rs.StatusCode != http.StatusOK {
+		t.Errorf("got status=%d, want %d", rs.StatusCode, http.StatusOK)
+	}
+	if cnt != 2 {
+		t.Errorf("DoWithCheckRetry(req) executed %d times, want 2 times", cnt)
+	}
+}
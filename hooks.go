@@ -0,0 +1,26 @@
+package retry
+
+import (
+	"net/http"
+	"time"
+)
+
+// Hooks lets callers observe each retry attempt, e.g. to emit Prometheus
+// counters, OpenTelemetry spans, or structured audit logs, without forking
+// the package. Any field left nil is simply not called.
+type Hooks struct {
+	// OnRetry is called after an attempt that will be retried, with the
+	// request and response/error of that attempt and the delay before the
+	// next one. resp is nil when the attempt failed at the transport level
+	// (err != nil, e.g. connection refused or a timeout) rather than with a
+	// retriable HTTP response, so callers must nil-check resp before
+	// touching resp.Body.
+	OnRetry func(attempt uint64, req *http.Request, resp *http.Response, err error, nextDelay time.Duration)
+	// OnGiveUp is called once, after the backoff policy stops retrying,
+	// with the request and response/error of the last attempt. resp is nil
+	// under the same condition as in OnRetry.
+	OnGiveUp func(req *http.Request, resp *http.Response, err error)
+	// OnSuccess is called once an attempt is accepted, with the request and
+	// response of that attempt. resp is always non-nil here.
+	OnSuccess func(attempt uint64, req *http.Request, resp *http.Response)
+}
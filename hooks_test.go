@@ -0,0 +1,150 @@
+package retry_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/golovers/retry"
+)
+
+func TestHooksOnRetryAndOnSuccess(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			cnt++
+		}()
+		if cnt == 0 {
+			http.Error(w, "server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	var retries uint64
+	var successes uint64
+	var gotBody string
+	hooks := &retry.Hooks{
+		OnRetry: func(attempt uint64, req *http.Request, resp *http.Response, err error, next time.Duration) {
+			atomic.AddUint64(&retries, 1)
+			if resp == nil {
+				return
+			}
+			// The losing response's body must still be readable here, before
+			// it gets drained and closed for connection reuse.
+			b, _ := ioutil.ReadAll(resp.Body)
+			gotBody = string(b)
+		},
+		OnSuccess: func(attempt uint64, req *http.Request, resp *http.Response) {
+			atomic.AddUint64(&successes, 1)
+		},
+	}
+	c := retry.New().WithHooks(hooks)
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	if _, err := c.Do(req); err != nil {
+		t.Errorf("Do(req) got error=%v, want error=nil", err)
+	}
+	if retries != 1 {
+		t.Errorf("OnRetry called %d times, want 1 time", retries)
+	}
+	if successes != 1 {
+		t.Errorf("OnSuccess called %d times, want 1 time", successes)
+	}
+	if !strings.Contains(gotBody, "server error") {
+		t.Errorf("OnRetry saw body %q, want it to contain %q (hook must run before the body is drained)", gotBody, "server error")
+	}
+}
+
+func TestHooksOnGiveUp(t *testing.T) {
+	t.Parallel()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "server error", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	var gaveUp uint64
+	hooks := &retry.Hooks{
+		OnGiveUp: func(req *http.Request, resp *http.Response, err error) {
+			atomic.AddUint64(&gaveUp, 1)
+		},
+	}
+	c := retry.New().WithHooks(hooks)
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if _, err := c.DoWithBackOffContext(ctx, req, retry.DefaultBackOff()); err == nil {
+		t.Errorf("DoWithBackOffContext(ctx, req) got error=nil, want error != nil")
+	}
+	if gaveUp != 1 {
+		t.Errorf("OnGiveUp called %d times, want 1 time", gaveUp)
+	}
+}
+
+// TestHooksOnRetryWithTransportErrorGetsNilResponse drives OnRetry through a
+// transport-level failure (connection refused) rather than a retriable HTTP
+// response, and asserts resp is nil there, matching the nilability
+// documented on Hooks.OnRetry.
+func TestHooksOnRetryWithTransportErrorGetsNilResponse(t *testing.T) {
+	t.Parallel()
+	// Listening then immediately closing reserves a port nothing answers on,
+	// so connections to it are refused.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port, err: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	var retries uint64
+	var sawNilResp uint64
+	hooks := &retry.Hooks{
+		OnRetry: func(attempt uint64, req *http.Request, resp *http.Response, err error, next time.Duration) {
+			atomic.AddUint64(&retries, 1)
+			if resp == nil {
+				atomic.AddUint64(&sawNilResp, 1)
+			}
+		},
+	}
+	c := retry.New().WithHooks(hooks)
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	// DefaultBackOff's 1s initial interval is longer than a short test
+	// context's whole remaining budget, so backoff.WithContext's NextBackOff
+	// would give up before ever attempting a retry. Use a policy with
+	// millisecond-scale intervals instead, so several retries fit well
+	// inside the context deadline below.
+	fastBackOff := backoff.WithMaxRetries(&backoff.ExponentialBackOff{
+		InitialInterval:     10 * time.Millisecond,
+		RandomizationFactor: 0,
+		Multiplier:          1.5,
+		MaxInterval:         50 * time.Millisecond,
+		Clock:               backoff.SystemClock,
+	}, 5)
+	fastBackOff.Reset()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := c.DoWithBackOffContext(ctx, req, fastBackOff); err == nil {
+		t.Errorf("DoWithBackOffContext(ctx, req) got error=nil, want error != nil")
+	}
+	if retries == 0 {
+		t.Fatalf("OnRetry was never called")
+	}
+	if sawNilResp != retries {
+		t.Errorf("OnRetry saw a non-nil resp on %d/%d calls, want resp == nil on every transport-error attempt", retries-sawNilResp, retries)
+	}
+}
@@ -0,0 +1,19 @@
+package retry
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// drainAndClose drains and closes the body of a response that lost a retry
+// and will never reach the caller. Otherwise the connection it came in on
+// can't be reused, and a sustained run of failures quickly exhausts
+// MaxIdleConnsPerHost.
+func drainAndClose(resp *http.Response) {
+	if resp == nil || resp.Body == nil {
+		return
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
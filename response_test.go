@@ -0,0 +1,77 @@
+package retry_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golovers/retry"
+)
+
+// TestDoDrainsLosingResponseBody runs more failing requests than
+// MaxIdleConnsPerHost allows idle connections for and asserts the number of
+// distinct connections opened stays bounded by MaxIdleConnsPerHost. Before
+// losing responses were drained and closed, the transport couldn't return
+// their connection to the idle pool, so every retry leaked a brand new one.
+func TestDoDrainsLosingResponseBody(t *testing.T) {
+	t.Parallel()
+	const maxIdleConnsPerHost = 1
+	const n = 5 // n > maxIdleConnsPerHost
+
+	var reqNum uint64
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			reqNum++
+		}()
+		if reqNum%2 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("a substantial body that must be drained before the connection can be reused"))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	var mu sync.Mutex
+	conns := map[net.Conn]bool{}
+	ts.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			conns[conn] = true
+			mu.Unlock()
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: maxIdleConnsPerHost,
+			IdleConnTimeout:     10 * time.Second,
+		},
+	}
+	c := retry.NewWithClient(httpClient).WithLogger(noopLogger{})
+
+	for i := 0; i < n; i++ {
+		req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+		if err != nil {
+			t.Errorf("failed to create request, err: %v", err)
+		}
+		if _, err := c.Do(req); err != nil {
+			t.Errorf("Do(req) got error=%v, want error=nil", err)
+		}
+	}
+
+	mu.Lock()
+	got := len(conns)
+	mu.Unlock()
+	if got > maxIdleConnsPerHost {
+		t.Errorf("opened %d connections across %d requests, want <= %d (losing responses must be drained so connections are reused)", got, n, maxIdleConnsPerHost)
+	}
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Errorf(format string, v ...interface{}) {}
+func (noopLogger) Infof(format string, v ...interface{})  {}
@@ -1,13 +1,10 @@
 package retry
 
 import (
-	"bytes"
+	"context"
 	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff"
@@ -23,8 +20,6 @@ type BackOff = backoff.BackOff
 // Func is a function to determine if a retry is needed base on the http.Response
 type Func = func(*http.Response) bool
 
-var responseKey = "response"
-
 // Logger is log interface that is used by the retry client
 type Logger interface {
 	Errorf(format string, v ...interface{})
@@ -35,6 +30,7 @@ type Logger interface {
 type Client struct {
 	c      *http.Client
 	logger Logger
+	hooks  *Hooks
 }
 
 // New return a new default retry client
@@ -68,11 +64,26 @@ func (c *Client) WithLogger(logger Logger) *Client {
 	return c
 }
 
+// WithHooks ask the client to call back into the given Hooks for every
+// retry attempt. See Hooks for what each callback receives.
+func (c *Client) WithHooks(hooks *Hooks) *Client {
+	c.hooks = hooks
+	return c
+}
+
 // Do execute the given request with default backoff policy and default retry func
 func (c *Client) Do(r *http.Request) (*http.Response, error) {
 	return c.DoWithBackOff(r, DefaultBackOff())
 }
 
+// DoWithContext is like Do but bounds the whole retry loop (including all
+// sleeps between attempts) to the lifetime of ctx. The context is attached
+// to the outgoing request on every attempt via r.WithContext(ctx), so an
+// in-flight attempt is also canceled as soon as ctx is done.
+func (c *Client) DoWithContext(ctx context.Context, r *http.Request) (*http.Response, error) {
+	return c.DoWithBackOffContext(ctx, r, DefaultBackOff())
+}
+
 // DoWithBackOff execute the given request with the given backoff policy.
 // It uses the DefaultRetryFunc which will retry if response status code
 // is in range of 500 but not http.StatusNotImplemented.
@@ -80,60 +91,110 @@ func (c *Client) DoWithBackOff(r *http.Request, b BackOff) (*http.Response, erro
 	return c.DoWithRetryFunc(r, b, DefaultRetryFunc)
 }
 
+// DoWithBackOffContext is like DoWithBackOff but cancellable via ctx. See
+// DoWithContext for the context semantics.
+func (c *Client) DoWithBackOffContext(ctx context.Context, r *http.Request, b BackOff) (*http.Response, error) {
+	return c.DoWithRetryFuncContext(ctx, r, b, DefaultRetryFunc)
+}
+
 // DoWithRetryFunc execute the given request with the given backoff policy.
 // A retry is determined by the given retry Func.
 func (c *Client) DoWithRetryFunc(r *http.Request, b BackOff, f Func) (*http.Response, error) {
-	response := sync.Map{}
-	var body []byte
-	var err error
-	copyBody := false
-	if r.Body != nil {
-		body, err = ioutil.ReadAll(r.Body)
-		if err != nil && err != io.EOF {
-			c.logger.Errorf("error while reading the request body, given up retrying. Err: %v", err)
-			return nil, backoff.Permanent(err)
-		}
-		r.Body.Close()
-		copyBody = true
+	return c.DoWithRetryFuncContext(context.Background(), r, b, f)
+}
+
+// DoWithRetryFuncContext is like DoWithRetryFunc but cancellable via ctx. See
+// DoWithContext for the context semantics.
+func (c *Client) DoWithRetryFuncContext(ctx context.Context, r *http.Request, b BackOff, f Func) (*http.Response, error) {
+	return c.doWithCheckRetryContext(ctx, r, b, asCheckRetry(f))
+}
+
+// doWithCheckRetryContext is the shared implementation behind
+// DoWithRetryFuncContext and DoWithCheckRetryContext.
+func (c *Client) doWithCheckRetryContext(ctx context.Context, r *http.Request, b BackOff, check CheckRetry) (*http.Response, error) {
+	rewind, err := prepareBody(r)
+	if err != nil {
+		c.logger.Errorf("error while reading the request body, given up retrying. Err: %v", err)
+		return nil, backoff.Permanent(err)
 	}
+	var final *http.Response
+	var attempt uint64
+	var lastResp *http.Response
+	var lastErr error
 	op := func() error {
-		if copyBody {
-			r.Body = ioutil.NopCloser(bytes.NewBuffer(body))
+		attempt++
+		if ctx.Err() != nil {
+			return backoff.Permanent(ctx.Err())
+		}
+		if err := rewind(); err != nil {
+			c.logger.Errorf("error while rewinding the request body, given up retrying. Err: %v", err)
+			return backoff.Permanent(err)
+		}
+		rs, doErr := c.c.Do(r.WithContext(ctx))
+		lastResp, lastErr = rs, doErr
+		if rab, ok := b.(*RetryAfterBackOff); ok {
+			rab.setResponse(rs)
 		}
-		rs, err := c.c.Do(r)
-		if err != nil {
-			c.logger.Errorf("request error, err: %v, need a retry", err)
-			return err
+		shouldRetry, checkErr := check(rs, doErr)
+		if checkErr != nil {
+			// rs, if any, is drained by the caller below, once hooks that
+			// may want to inspect it (OnGiveUp) have had a chance to run.
+			c.logger.Errorf("non-retriable error, given up retrying. Err: %v", checkErr)
+			return backoff.Permanent(checkErr)
 		}
-		response.Store(responseKey, rs)
-		if f(rs) {
-			c.logger.Errorf("got response from server: %+v, a retry is needed", rs)
-			return errors.New("need retry")
+		if !shouldRetry {
+			if doErr != nil {
+				return backoff.Permanent(doErr)
+			}
+			c.logger.Infof("executed successfully, response: %+v", rs)
+			if c.hooks != nil && c.hooks.OnSuccess != nil {
+				c.hooks.OnSuccess(attempt, r, rs)
+			}
+			final = rs
+			return nil
 		}
-		c.logger.Infof("executed successfully, response: %+v", rs)
-		return nil
+		if doErr != nil {
+			c.logger.Errorf("request error, err: %v, need a retry", doErr)
+			return doErr
+		}
+		c.logger.Errorf("got response from server: %+v, a retry is needed", rs)
+		return errors.New("need retry")
+	}
+	// notify runs once per retried attempt, before the next one starts. It
+	// gives Hooks.OnRetry a chance to inspect lastResp (e.g. its body, for a
+	// structured audit log) before draining it so the connection it came in
+	// on can be reused.
+	notify := func(err error, next time.Duration) {
+		if c.hooks != nil && c.hooks.OnRetry != nil {
+			c.hooks.OnRetry(attempt, r, lastResp, lastErr, next)
+		}
+		drainAndClose(lastResp)
 	}
-	if err := backoff.Retry(op, b); err != nil {
+	if err := backoff.RetryNotify(op, backoff.WithContext(b, ctx), notify); err != nil {
+		// A permanent error returned by the check, or the last attempt of an
+		// exhausted backoff, never reaches notify, so lastResp is still
+		// undrained here. Give Hooks.OnGiveUp the same chance to inspect it
+		// before draining. When the loop instead stops before an attempt is
+		// made (ctx done, or the body could not be rewound), lastResp is the
+		// previous attempt's response, which notify has already drained.
+		if c.hooks != nil && c.hooks.OnGiveUp != nil {
+			c.hooks.OnGiveUp(r, lastResp, lastErr)
+		}
+		drainAndClose(lastResp)
 		return nil, fmt.Errorf("failed to retried, err: %v", err)
 	}
-	v, ok := response.Load(responseKey)
-	if !ok {
+	if final == nil {
 		return nil, errors.New("executed request successfully, but failed to get response. Propably a bug of retry")
 	}
-	return v.(*http.Response), nil
+	return final, nil
 }
 
-// DefaultBackOff return a backoff policy with exponential backoff wrapped with a 10-times-max-retry.
+// DefaultBackOff return a backoff policy with exponential backoff wrapped
+// with a 10-times-max-retry. A randomization factor of 0.5 is applied so
+// that a fleet of clients hitting the same failing endpoint don't retry in
+// lockstep.
 func DefaultBackOff() BackOff {
-	b := backoff.WithMaxRetries(&backoff.ExponentialBackOff{
-		InitialInterval:     1 * time.Second,
-		RandomizationFactor: 0,
-		Multiplier:          2,
-		MaxInterval:         60 * time.Second,
-		Clock:               backoff.SystemClock,
-	}, DefaultMaxRetry)
-	b.Reset()
-	return b
+	return WithJitter(0.5)
 }
 
 // DefaultRetryFunc retry when the response status code is in range of 500
@@ -1,10 +1,12 @@
 package retry_test
 
 import (
+	"context"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/golovers/retry"
 )
@@ -68,3 +70,27 @@ func TestRetrySuccessAtSecondTime(t *testing.T) {
 		t.Errorf("Do(req) executed %d times, want %d times", cnt, 2)
 	}
 }
+
+func TestDoWithContextCanceled(t *testing.T) {
+	t.Parallel()
+	cnt := uint64(0)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cnt++
+		http.Error(w, "server error", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	c := retry.New()
+	req, err := http.NewRequest(http.MethodGet, ts.URL, nil)
+	if err != nil {
+		t.Errorf("failed to create request, err: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.DoWithContext(ctx, req); err == nil {
+		t.Errorf("DoWithContext(ctx, req) got error=nil, want error != nil")
+	}
+	// the deadline must stop the retry loop well before DefaultMaxRetry attempts.
+	if cnt > retry.DefaultMaxRetry {
+		t.Errorf("DoWithContext(ctx, req) executed %d times, want <= %d times", cnt, retry.DefaultMaxRetry)
+	}
+}